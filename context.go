@@ -0,0 +1,22 @@
+package main
+
+import "context"
+
+// NewTailContext is like NewTailWithOptions but ties the Tail's lifetime
+// to ctx: cancelling ctx closes the Tail exactly as calling Close() would.
+// This lets tailing compose with the rest of a caller's program (HTTP
+// handlers, errgroups, deadlines, ...) instead of requiring an explicit
+// Close() call on every exit path.
+func NewTailContext(ctx context.Context, fileName string, opts ...Option) *Tail {
+	t := newTail(fileName, opts...)
+	t.ctx = ctx
+	go func() {
+		select {
+		case <-ctx.Done():
+			t.Close()
+		case <-t.done:
+		}
+	}()
+	go readLines(t)
+	return t
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+)
+
+// Decoder turns the byte stream read from a tailed file into discrete
+// messages. Tail uses it in place of the hard-coded newline scanner so it
+// can follow structured or multiline streams (NDJSON, length-prefixed
+// records, Java/Python stack traces, ...) as well as plain text.
+//
+// Decode returns the next decoded message. It returns io.EOF when the
+// underlying reader currently has no more data; Tail waits for the file
+// to grow (or for the next poll/fsnotify event) and calls Decode again,
+// so io.EOF here does not mean the stream is over. Reset rebinds the
+// decoder to read from r from the start, discarding any buffered state;
+// Tail calls it whenever it (re)opens the file, including after a log
+// rotation.
+type Decoder interface {
+	Decode() ([]byte, error)
+	Reset(r io.Reader)
+}
+
+// partialFlusher is implemented by decoders that can give back bytes
+// buffered for a not-yet-complete message. reopenAfterRotation uses it to
+// recover a trailing line left without its terminator when a file is
+// rotated away; decoders that don't implement it simply drop any
+// unterminated tail, which is the preexisting behavior for those.
+type partialFlusher interface {
+	flushPartial() ([]byte, bool)
+}
+
+// WithDecoder makes the Tail split the file's byte stream into messages
+// using d instead of the default newline-delimited text decoder.
+func WithDecoder(d Decoder) Option {
+	return func(t *Tail) {
+		t.decoder = d
+	}
+}
+
+// lineDecoder splits its input on '\n', stripping a trailing '\r' from
+// each line. It implements today's default line-tailing behavior.
+type lineDecoder struct {
+	r      io.Reader
+	buf    []byte
+	nbytes int
+}
+
+func newLineDecoder() *lineDecoder {
+	return &lineDecoder{buf: make([]byte, bufInitSize)}
+}
+
+func (d *lineDecoder) Reset(r io.Reader) {
+	d.r = r
+	d.nbytes = 0
+}
+
+// flushPartial returns any buffered bytes that didn't yet form a
+// complete line, and true if there were any. It lets a rotation handler
+// recover a trailing line left without its terminating '\n' when the
+// file is going away for good.
+func (d *lineDecoder) flushPartial() ([]byte, bool) {
+	if d.nbytes == 0 {
+		return nil, false
+	}
+	b := make([]byte, d.nbytes)
+	copy(b, d.buf[:d.nbytes])
+	d.nbytes = 0
+	return b, true
+}
+
+func (d *lineDecoder) Decode() ([]byte, error) {
+	for {
+		if i := bytes.IndexByte(d.buf[:d.nbytes], '\n'); i >= 0 {
+			end := i
+			if end > 0 && d.buf[end-1] == '\r' {
+				end--
+			}
+			line := make([]byte, end)
+			copy(line, d.buf[:end])
+			d.nbytes = copy(d.buf, d.buf[i+1:d.nbytes])
+			return line, nil
+		}
+		if d.nbytes == len(d.buf) {
+			tmp := make([]byte, len(d.buf)*2)
+			copy(tmp, d.buf[:d.nbytes])
+			d.buf = tmp
+		}
+		n, err := d.r.Read(d.buf[d.nbytes:])
+		d.nbytes += n
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// ndjsonDecoder decodes newline-delimited JSON, rejecting lines that are
+// not well-formed JSON values.
+type ndjsonDecoder struct {
+	lines *lineDecoder
+}
+
+// NewNDJSONDecoder returns a Decoder for newline-delimited JSON streams.
+// Decode returns an error if a line is not valid JSON.
+func NewNDJSONDecoder() Decoder {
+	return &ndjsonDecoder{lines: newLineDecoder()}
+}
+
+func (d *ndjsonDecoder) Reset(r io.Reader) { d.lines.Reset(r) }
+
+func (d *ndjsonDecoder) Decode() ([]byte, error) {
+	line, err := d.lines.Decode()
+	if err != nil {
+		return nil, err
+	}
+	if !json.Valid(line) {
+		return nil, fmt.Errorf("tail: invalid NDJSON line: %q", line)
+	}
+	return line, nil
+}
+
+// lengthPrefixedDecoder decodes records framed with a fixed 4-byte
+// big-endian length prefix followed by that many bytes of payload.
+type lengthPrefixedDecoder struct {
+	r      io.Reader
+	buf    []byte
+	nbytes int
+}
+
+// NewLengthPrefixedDecoder returns a Decoder for records framed with a
+// 4-byte big-endian length prefix.
+func NewLengthPrefixedDecoder() Decoder {
+	return &lengthPrefixedDecoder{buf: make([]byte, bufInitSize)}
+}
+
+func (d *lengthPrefixedDecoder) Reset(r io.Reader) {
+	d.r = r
+	d.nbytes = 0
+}
+
+const lengthPrefixSize = 4
+
+func (d *lengthPrefixedDecoder) Decode() ([]byte, error) {
+	for {
+		if d.nbytes >= lengthPrefixSize {
+			size := int(binary.BigEndian.Uint32(d.buf[:lengthPrefixSize]))
+			frameSize := lengthPrefixSize + size
+			if d.nbytes >= frameSize {
+				body := make([]byte, size)
+				copy(body, d.buf[lengthPrefixSize:frameSize])
+				d.nbytes = copy(d.buf, d.buf[frameSize:d.nbytes])
+				return body, nil
+			}
+			if frameSize > len(d.buf) {
+				tmp := make([]byte, frameSize)
+				copy(tmp, d.buf[:d.nbytes])
+				d.buf = tmp
+			}
+		} else if d.nbytes == len(d.buf) {
+			tmp := make([]byte, len(d.buf)*2)
+			copy(tmp, d.buf[:d.nbytes])
+			d.buf = tmp
+		}
+		n, err := d.r.Read(d.buf[d.nbytes:])
+		d.nbytes += n
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// multilineDecoder joins a line together with any lines following it
+// that match continuation, concatenating them with '\n' into a single
+// message. It is meant for tailing Java/Python stack traces and similar
+// multiline records, where continuation typically matches indented or
+// otherwise clearly-continued lines.
+//
+// Because a block is only known to be complete once a following
+// non-continuation line (or closing of the Tail) is seen, the very last
+// block of a file is held back until then.
+type multilineDecoder struct {
+	lines        *lineDecoder
+	continuation *regexp.Regexp
+	pending      []byte
+	havePending  bool
+}
+
+// NewMultilineDecoder returns a Decoder that joins consecutive lines
+// matching continuation onto the line that precedes them.
+func NewMultilineDecoder(continuation *regexp.Regexp) Decoder {
+	return &multilineDecoder{lines: newLineDecoder(), continuation: continuation}
+}
+
+func (d *multilineDecoder) Reset(r io.Reader) {
+	d.lines.Reset(r)
+	d.pending = nil
+	d.havePending = false
+}
+
+// flushPartial returns the block accumulated so far if one hasn't yet
+// been confirmed complete by a following non-continuation line, mirroring
+// lineDecoder's handling of an unterminated tail. Without this, the last
+// block in a file (e.g. the final captured stack trace) is simply lost
+// whenever the Tail stops before a later line can close it out, whether
+// from a rotation or from Close.
+func (d *multilineDecoder) flushPartial() ([]byte, bool) {
+	if !d.havePending {
+		return nil, false
+	}
+	b := d.pending
+	d.pending = nil
+	d.havePending = false
+	return b, true
+}
+
+func (d *multilineDecoder) Decode() ([]byte, error) {
+	for {
+		line, err := d.lines.Decode()
+		if err != nil {
+			return nil, err
+		}
+		if d.havePending && d.continuation.Match(line) {
+			d.pending = append(d.pending, '\n')
+			d.pending = append(d.pending, line...)
+			continue
+		}
+		if d.havePending {
+			out := d.pending
+			d.pending = append([]byte(nil), line...)
+			return out, nil
+		}
+		d.pending = append([]byte(nil), line...)
+		d.havePending = true
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/binary"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func decodeAll(d Decoder) []string {
+	var got []string
+	for {
+		b, err := d.Decode()
+		if err != nil {
+			return got
+		}
+		got = append(got, string(b))
+	}
+}
+
+func assertLines(t *testing.T, got, want []string) {
+	if len(got) != len(want) {
+		t.Fatalf("got %d messages %q, want %d %q", len(got), got, len(want), want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("message %d: got %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestLineDecoderRoundTrip(t *testing.T) {
+	d := newLineDecoder()
+	d.Reset(strings.NewReader("line 1\nline 2\r\nline 3\n"))
+	assertLines(t, decodeAll(d), []string{"line 1", "line 2", "line 3"})
+}
+
+func TestNDJSONDecoderRoundTrip(t *testing.T) {
+	d := NewNDJSONDecoder()
+	d.Reset(strings.NewReader(`{"a":1}` + "\n" + `{"b":2}` + "\n"))
+	assertLines(t, decodeAll(d), []string{`{"a":1}`, `{"b":2}`})
+}
+
+func TestNDJSONDecoderRejectsInvalidLine(t *testing.T) {
+	d := NewNDJSONDecoder()
+	d.Reset(strings.NewReader(`{"a":1}` + "\nnot json\n"))
+	if _, err := d.Decode(); err != nil {
+		t.Fatal("unexpected error on valid line:", err)
+	}
+	if _, err := d.Decode(); err == nil {
+		t.Fatal("expected an error decoding a non-JSON line")
+	}
+}
+
+func lengthPrefixedFrame(s string) []byte {
+	b := []byte(s)
+	frame := make([]byte, lengthPrefixSize+len(b))
+	binary.BigEndian.PutUint32(frame, uint32(len(b)))
+	copy(frame[lengthPrefixSize:], b)
+	return frame
+}
+
+func TestLengthPrefixedDecoderRoundTrip(t *testing.T) {
+	var buf []byte
+	buf = append(buf, lengthPrefixedFrame("first")...)
+	buf = append(buf, lengthPrefixedFrame("second")...)
+
+	d := NewLengthPrefixedDecoder()
+	d.Reset(strings.NewReader(string(buf)))
+	assertLines(t, decodeAll(d), []string{"first", "second"})
+}
+
+func TestMultilineDecoderRoundTrip(t *testing.T) {
+	d := NewMultilineDecoder(regexp.MustCompile(`^\s`))
+	d.Reset(strings.NewReader("ERROR: boom\n  at foo\n  at bar\nINFO: done\n"))
+
+	got := decodeAll(d)
+	assertLines(t, got, []string{"ERROR: boom\n  at foo\n  at bar"})
+
+	f, ok := d.(partialFlusher)
+	if !ok {
+		t.Fatal("multilineDecoder must implement partialFlusher")
+	}
+	b, ok := f.flushPartial()
+	if !ok {
+		t.Fatal("expected a pending block for the trailing 'INFO: done' line")
+	}
+	if string(b) != "INFO: done" {
+		t.Fatal("expected 'INFO: done', got", string(b))
+	}
+	if _, ok := f.flushPartial(); ok {
+		t.Fatal("flushPartial should report nothing pending once already flushed")
+	}
+}
+
+func TestMultilineDecoderFlushesFinalBlockWithoutClosingLine(t *testing.T) {
+	d := NewMultilineDecoder(regexp.MustCompile(`^\s`))
+	d.Reset(strings.NewReader("ERROR: boom\n  at foo\n  at bar\n"))
+
+	if got := decodeAll(d); len(got) != 0 {
+		t.Fatal("expected the only block to be held back as pending, got", got)
+	}
+
+	f := d.(partialFlusher)
+	b, ok := f.flushPartial()
+	if !ok {
+		t.Fatal("expected the held-back block to be recoverable via flushPartial")
+	}
+	want := "ERROR: boom\n  at foo\n  at bar"
+	if string(b) != want {
+		t.Fatalf("got %q, want %q", string(b), want)
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+// NewMultiTail follows several files or glob patterns at once, fanning
+// their lines into a single Event channel. It keeps Tail's own chan
+// string API untouched; MultiTail is an additional, independent entry
+// point for the common case of tailing rotated or sharded logs
+// (/var/log/*.log, one file per shard, ...).
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Event is one line read from one of the files a MultiTail is watching.
+type Event struct {
+	Path   string    // path of the file the line was read from
+	Line   string    // the line content
+	Offset int64     // approximate byte offset of Line within Path
+	Time   time.Time // time the line was read
+}
+
+// MultiTail tails every file matching any of a set of literal paths or
+// glob patterns (e.g. "/var/log/*.log"), watching each pattern's
+// directory so files created later are picked up automatically.
+type MultiTail struct {
+	Event chan Event
+	Error chan error
+	done  chan struct{}
+
+	patternsByDir map[string][]string
+	watcher       *fsnotify.Watcher
+
+	mu    sync.Mutex
+	tails map[string]*Tail
+}
+
+// NewMultiTail starts tailing every existing file matching patterns and
+// watches for new matches appearing later.
+func NewMultiTail(patterns ...string) *MultiTail {
+	mt := &MultiTail{
+		Event:         make(chan Event, lineChanSize),
+		Error:         make(chan error, 1),
+		done:          make(chan struct{}),
+		patternsByDir: make(map[string][]string),
+		tails:         make(map[string]*Tail),
+	}
+	for _, p := range patterns {
+		dir := filepath.Dir(p)
+		mt.patternsByDir[dir] = append(mt.patternsByDir[dir], p)
+	}
+	go mt.run(patterns)
+	return mt
+}
+
+// Close stops tailing every file. Event and Error are not closed. Has no
+// effect if the MultiTail is already closed.
+func (mt *MultiTail) Close() {
+	if mt.IsClosed() {
+		return
+	}
+	close(mt.done)
+	mt.mu.Lock()
+	for _, t := range mt.tails {
+		t.Close()
+	}
+	mt.mu.Unlock()
+	if mt.watcher != nil {
+		mt.watcher.Close()
+	}
+}
+
+// IsClosed return true once the MultiTail has been closed.
+func (mt *MultiTail) IsClosed() bool {
+	select {
+	case <-mt.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (mt *MultiTail) run(patterns []string) {
+	var err error
+	mt.watcher, err = fsnotify.NewWatcher()
+	if err != nil {
+		mt.Error <- err
+		return
+	}
+
+	for dir := range mt.patternsByDir {
+		if err := mt.watcher.Add(dir); err != nil {
+			mt.reportError(fmt.Errorf("%s: %w", dir, err))
+		}
+	}
+
+	for _, p := range patterns {
+		matches, err := filepath.Glob(p)
+		if err != nil {
+			mt.reportError(fmt.Errorf("%s: %w", p, err))
+			continue
+		}
+		for _, path := range matches {
+			mt.addTail(path)
+		}
+	}
+
+	for {
+		select {
+		case <-mt.done:
+			return
+		case event, ok := <-mt.watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Op&fsnotify.Create == fsnotify.Create {
+				mt.maybeAddTail(event.Name)
+			}
+		case err, ok := <-mt.watcher.Errors:
+			if !ok {
+				return
+			}
+			mt.reportError(err)
+		}
+	}
+}
+
+// maybeAddTail starts tailing path if it matches one of the patterns
+// whose directory it was created in.
+func (mt *MultiTail) maybeAddTail(path string) {
+	for _, p := range mt.patternsByDir[filepath.Dir(path)] {
+		if ok, _ := filepath.Match(p, path); ok {
+			mt.addTail(path)
+			return
+		}
+	}
+}
+
+// forgetTail removes path's entry from mt.tails once its Tail has died,
+// so a later matching Create event (or a fresh addTail call) can start
+// tailing it again instead of finding a stale map entry.
+func (mt *MultiTail) forgetTail(path string, t *Tail) {
+	mt.mu.Lock()
+	if mt.tails[path] == t {
+		delete(mt.tails, path)
+	}
+	mt.mu.Unlock()
+}
+
+func (mt *MultiTail) addTail(path string) {
+	mt.mu.Lock()
+	if _, ok := mt.tails[path]; ok {
+		mt.mu.Unlock()
+		return
+	}
+	t := NewTail(path)
+	mt.tails[path] = t
+	mt.mu.Unlock()
+
+	go mt.pump(path, t)
+}
+
+// pump relays lines and errors from one file's Tail onto the MultiTail's
+// Event and Error channels until the file's Tail or the MultiTail itself
+// is closed. Tail never closes Line or Error, so pump must watch t.done
+// directly to notice the Tail has died; otherwise it would park forever
+// and leave a stale entry in mt.tails that blocks path from ever being
+// re-added, e.g. after the file it named is rotated away for good.
+func (mt *MultiTail) pump(path string, t *Tail) {
+	defer mt.forgetTail(path, t)
+
+	var offset int64
+	for {
+		select {
+		case <-mt.done:
+			return
+		case <-t.done:
+			return
+		case line, ok := <-t.Line:
+			if !ok {
+				return
+			}
+			event := Event{Path: path, Line: line, Offset: offset, Time: time.Now()}
+			offset += int64(len(line)) + 1
+			select {
+			case mt.Event <- event:
+			case <-mt.done:
+				return
+			}
+		case err, ok := <-t.Error:
+			if !ok {
+				continue
+			}
+			mt.reportError(fmt.Errorf("%s: %w", path, err))
+		}
+	}
+}
+
+// reportError forwards err to Error, dropping it if a caller isn't
+// reading and a previous error is already buffered; with several files
+// able to fail independently and concurrently, MultiTail can't afford to
+// block on a slow or absent Error reader the way a single Tail can.
+func (mt *MultiTail) reportError(err error) {
+	select {
+	case mt.Error <- err:
+	case <-mt.done:
+	default:
+	}
+}
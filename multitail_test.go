@@ -0,0 +1,119 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func recvEvent(t *testing.T, mt *MultiTail, want string) Event {
+	select {
+	case ev, ok := <-mt.Event:
+		if !ok {
+			t.Fatal("Event closed unexpectedly waiting for", want)
+		}
+		if ev.Line != want {
+			t.Fatal("expected", want, "got", ev.Line)
+		}
+		return ev
+	case err := <-mt.Error:
+		t.Fatal("unexpected error:", err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for", want)
+	}
+	return Event{}
+}
+
+func TestMultiTailGlobAndCreate(t *testing.T) {
+	dir, err := os.MkdirTemp("", "test_go_multitail_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fileA := filepath.Join(dir, "a.log")
+	fileB := filepath.Join(dir, "b.log")
+	if err := os.WriteFile(fileA, []byte("a line 1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(fileB, []byte("b line 1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	mt := NewMultiTail(filepath.Join(dir, "*.log"))
+	defer mt.Close()
+
+	seen := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		var ev Event
+		select {
+		case ev = <-mt.Event:
+		case err := <-mt.Error:
+			t.Fatal("unexpected error:", err)
+		case <-time.After(2 * time.Second):
+			t.Fatal("timed out waiting for initial lines")
+		}
+		seen[ev.Path+":"+ev.Line] = true
+	}
+	if !seen[fileA+":a line 1"] || !seen[fileB+":b line 1"] {
+		t.Fatal("expected a line from each glob match, got", seen)
+	}
+
+	// a file created after MultiTail has started, matching the same glob,
+	// must be picked up automatically
+	fileC := filepath.Join(dir, "c.log")
+	if err := os.WriteFile(fileC, []byte("c line 1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ev := recvEvent(t, mt, "c line 1")
+	if ev.Path != fileC {
+		t.Fatal("expected path", fileC, "got", ev.Path)
+	}
+
+	f, err := os.OpenFile(fileA, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte("a line 2\n"))
+	f.Close()
+	recvEvent(t, mt, "a line 2")
+}
+
+func TestMultiTailCloseDrainsLiveTails(t *testing.T) {
+	dir, err := os.MkdirTemp("", "test_go_multitail_close_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fileA := filepath.Join(dir, "a.log")
+	if err := os.WriteFile(fileA, []byte("a line 1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	mt := NewMultiTail(filepath.Join(dir, "*.log"))
+	recvEvent(t, mt, "a line 1")
+
+	mt.Close()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !mt.IsClosed() {
+		if time.Now().After(deadline) {
+			t.Fatal("MultiTail did not report closed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	mt.mu.Lock()
+	for path, tail := range mt.tails {
+		if !tail.IsClosed() {
+			mt.mu.Unlock()
+			t.Fatal("expected per-file Tail for", path, "to be closed too")
+		}
+	}
+	mt.mu.Unlock()
+
+	// closing twice must not panic
+	mt.Close()
+}
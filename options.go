@@ -0,0 +1,53 @@
+package main
+
+import "time"
+
+// Option configures a Tail created with NewTailWithOptions.
+type Option func(*Tail)
+
+// Backend selects the mechanism a Tail uses to detect file changes.
+type Backend int
+
+const (
+	// BackendFSNotify watches the file with fsnotify. This is the default.
+	BackendFSNotify Backend = iota
+	// BackendPoll periodically Stats the file instead of using fsnotify.
+	// Useful on network filesystems (NFS, SMB, FUSE) and Windows shares
+	// where fsnotify is unreliable or unsupported.
+	BackendPoll
+	// BackendAuto prefers fsnotify but falls back to BackendPoll when
+	// watcher.Add fails on the file's filesystem.
+	BackendAuto
+)
+
+// defaultPollInterval is the polling interval used by BackendPoll and
+// BackendAuto when WithPollInterval was not given. BackendFSNotify also
+// uses it, as the cadence of its own liveness check (see followFSNotify).
+const defaultPollInterval = 250 * time.Millisecond
+
+// WithBackend selects the mechanism used to detect file changes.
+func WithBackend(b Backend) Option {
+	return func(t *Tail) {
+		t.backend = b
+	}
+}
+
+// WithPollInterval sets the interval between file size checks when the
+// Tail is using BackendPoll or falls back to it under BackendAuto. Under
+// BackendFSNotify it instead sets the cadence of the periodic liveness
+// check that catches rotations fsnotify alone can miss.
+func WithPollInterval(d time.Duration) Option {
+	return func(t *Tail) {
+		t.poll = d
+	}
+}
+
+// WithTailLines makes the Tail emit only the last n lines already present
+// in the file before following new appends, instead of emitting the whole
+// file from the start. Passing n == 0 skips the existing content
+// entirely, so only lines appended after the Tail starts are emitted.
+func WithTailLines(n int) Option {
+	return func(t *Tail) {
+		t.tailN = n
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"io"
+	"os"
+	"time"
+)
+
+// defaultReopenRetry is the default max time reopenAfterRotation waits for
+// a rotated file to reappear before giving up.
+const defaultReopenRetry = 5 * time.Second
+
+const (
+	reopenMinBackoff = 50 * time.Millisecond
+	reopenMaxBackoff = time.Second
+)
+
+// WithReopenRetry sets the max time the Tail waits for a file to reappear
+// after a rotation (rename, remove or truncate) before giving up and
+// reporting an error. Tune this up for slow copytruncate-then-recreate
+// workflows, or down for bounded-latency use cases.
+func WithReopenRetry(d time.Duration) Option {
+	return func(t *Tail) {
+		t.reopenRetry = d
+	}
+}
+
+// rotated reports whether the file at t.fileName no longer refers to the
+// same file as the currently open t.file, or has shrunk since it was last
+// read, either of which indicates the file was rotated out from under
+// us. A copytruncate rotates the file in place, so t.file.Stat() still
+// reports the post-truncate size of the very same fd/inode as
+// os.Stat(t.fileName) by the time we check; the comparison has to be
+// against t.lastSize, the size as of our last read, not against the fd's
+// current size. When rotated reports false, it also refreshes t.lastSize
+// from the current on-disk size so later checks compare against
+// up-to-date state.
+func (t *Tail) rotated() (bool, error) {
+	pathStat, err := os.Stat(t.fileName)
+	if os.IsNotExist(err) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if pathStat.Size() < t.lastSize {
+		return true, nil
+	}
+	fdStat, err := t.file.Stat()
+	if err != nil {
+		return false, err
+	}
+	if !os.SameFile(pathStat, fdStat) {
+		return true, nil
+	}
+	t.lastSize = pathStat.Size()
+	return false, nil
+}
+
+// reopenAfterRotation drains and closes the current file, then retries
+// opening t.fileName with bounded exponential backoff until a new file
+// appears or ReopenRetry elapses. On success it resumes scanning lines
+// from the start of the new file and, when using fsnotify, re-adds the
+// watch on t.fileName.
+func (t *Tail) reopenAfterRotation() error {
+	if t.file != nil {
+		if err := t.scanLines(); err != nil && err != io.EOF {
+			return err
+		}
+		if f, ok := t.decoder.(partialFlusher); ok {
+			// the file is going away, so any bytes buffered for a
+			// not-yet-complete message are now the last it will produce
+			if b, ok := f.flushPartial(); ok {
+				t.outputLine(b)
+			}
+		}
+		t.file.Close()
+		t.file = nil
+	}
+
+	retry := t.reopenRetry
+	if retry <= 0 {
+		retry = defaultReopenRetry
+	}
+	deadline := time.Now().Add(retry)
+
+	backoff := reopenMinBackoff
+	var err error
+	for {
+		if err = t.openFile(); err == nil {
+			break
+		}
+		if !os.IsNotExist(err) {
+			return err
+		}
+		if time.Now().After(deadline) {
+			return err
+		}
+		select {
+		case <-t.done:
+			return nil
+		case <-t.ctx.Done():
+			return nil
+		case <-time.After(backoff):
+		}
+		if backoff < reopenMaxBackoff {
+			backoff *= 2
+		}
+	}
+
+	if t.watcher != nil {
+		t.watcher.Remove(t.fileName)
+		if err := t.watcher.Add(t.fileName); err != nil {
+			return err
+		}
+	}
+	if err := t.scanLines(); err != io.EOF {
+		return err
+	}
+	return nil
+}
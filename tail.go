@@ -4,8 +4,10 @@ package main
 // keep returning lines when appended at runtime.
 
 import (
+	"context"
 	"io"
 	"os"
+	"time"
 
 	"github.com/fsnotify/fsnotify"
 )
@@ -18,38 +20,64 @@ var (
 )
 
 type Tail struct {
-	fileName string            // name of file to read lines from
-	file     *os.File          // file we read lines from
-	Line     chan string       // channel to return line strings
-	Error    chan error        // channel to report errors
-	done     chan struct{}     // channel to signal Tail close
-	buf      []byte            // reading buffer
-	nbytes   int               // number valid bytes in buffer
-	lastSize int64             // last file files
-	watcher  *fsnotify.Watcher // watcher on file
+	fileName    string            // name of file to read lines from
+	file        *os.File          // file we read lines from
+	Line        chan string       // channel to return line strings
+	Error       chan error        // channel to report errors
+	done        chan struct{}     // channel to signal Tail close
+	decoder     Decoder           // splits the file's byte stream into messages
+	lastSize    int64             // last file files
+	watcher     *fsnotify.Watcher // watcher on file, nil when following by polling
+	tailN       int               // number of trailing lines to emit before following, -1 to read from start
+	backend     Backend           // mechanism used to detect file changes
+	poll        time.Duration     // polling interval when polling, liveness check interval under fsnotify
+	reopenRetry time.Duration     // max time to wait for a rotated file to reappear
+	ctx         context.Context   // cancelling it closes the Tail, like calling Close()
 }
 
 func NewTail(fileName string) *Tail {
+	return NewTailWithOptions(fileName)
+}
+
+// NewTailWithOptions is like NewTail but accepts Options configuring how
+// the Tail behaves, such as starting from the last N lines of the file
+// instead of from the beginning.
+func NewTailWithOptions(fileName string, opts ...Option) *Tail {
+	t := newTail(fileName, opts...)
+	go readLines(t)
+	return t
+}
+
+// newTail builds and configures a Tail but does not start following the
+// file; callers are responsible for starting the readLines goroutine.
+func newTail(fileName string, opts ...Option) *Tail {
 	t := &Tail{
 		fileName: fileName,
 		Line:     make(chan string, lineChanSize),
 		Error:    make(chan error, 1),
 		done:     make(chan struct{}),
-		buf:      make([]byte, bufInitSize),
+		decoder:  newLineDecoder(),
+		tailN:    -1,
+		ctx:      context.Background(),
+	}
+	for _, opt := range opts {
+		opt(t)
 	}
-	go readLines(t)
 	return t
 }
 
 // Close terminates monitoring the file and close the channel. Has no
 // effect if the Tail is already closed.
+//
+// Close only signals done; t.file and t.watcher are owned by the
+// readLines goroutine and are closed from its own deferred cleanup once
+// it observes done closed, so Close never touches them directly. That
+// keeps those fields single-writer, since Close can otherwise be called
+// concurrently with readLines still initializing them (e.g. from the
+// ctx-cancellation goroutine started by NewTailContext).
 func (t *Tail) Close() {
 	if !t.IsClosed() {
 		close(t.done)
-		if t.watcher != nil {
-			t.watcher.Close()
-			t.watcher = nil
-		}
 	}
 }
 
@@ -64,11 +92,17 @@ func (t *Tail) IsClosed() bool {
 }
 
 // outputLine return true if successfully output b as a line, and false
-// if tail has been closed.
+// if tail has been closed or its context cancelled.
 func (t *Tail) outputLine(b []byte) bool {
+	ctx := t.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
 	select {
 	case <-t.done:
 		return false
+	case <-ctx.Done():
+		return false
 	case t.Line <- string(b):
 		return true
 	}
@@ -88,6 +122,9 @@ func (t *Tail) openFile() error {
 		return err
 	}
 	t.lastSize = stat.Size()
+	if t.decoder != nil {
+		t.decoder.Reset(t.file)
+	}
 	return nil
 }
 
@@ -95,6 +132,11 @@ func (t *Tail) openFile() error {
 func readLines(t *Tail) {
 	var err error
 	defer func() {
+		t.flushDecoderTail()
+		if t.watcher != nil {
+			t.watcher.Close()
+			t.watcher = nil
+		}
 		if t.file != nil {
 			t.file.Close()
 			t.file = nil
@@ -105,87 +147,247 @@ func readLines(t *Tail) {
 		t.Close()
 	}()
 
-	// try starting watcher
-	if t.watcher, err = fsnotify.NewWatcher(); err != nil || testError != nil {
-		if err == nil {
-			err = testError
+	// try starting the fsnotify watcher, unless polling was requested
+	if t.backend != BackendPoll {
+		if t.watcher, err = fsnotify.NewWatcher(); err != nil || testError != nil {
+			if err == nil {
+				err = testError
+			}
+			return
 		}
-		return
 	}
 
 	// try open file
 	if err = t.openFile(); err != nil {
 		return
 	}
-	// read all existing lines in file
-	if err = t.scanLines(); err != io.EOF {
-		return
+
+	if t.tailN >= 0 {
+		// emit only the last tailN lines already in the file, then
+		// position the file so the follow loop picks up from there
+		if err = t.seekLastLines(t.tailN); err != nil {
+			return
+		}
+	} else {
+		// read all existing lines in file
+		if err = t.scanLines(); err != io.EOF {
+			return
+		}
 	}
 
-	// start watching file to detect appending or file renaming
-	if err = t.watcher.Add(t.fileName); err != nil || testError2 != nil {
-		if err == nil {
-			err = testError2
+	if t.watcher != nil {
+		// start watching file to detect appending or file renaming
+		if err = t.watcher.Add(t.fileName); err != nil || testError2 != nil {
+			if err == nil {
+				err = testError2
+			}
+			if t.backend != BackendAuto {
+				return
+			}
+			// fsnotify is unusable on this filesystem, fall back to polling
+			t.watcher.Close()
+			t.watcher = nil
+			err = nil
 		}
-		return
 	}
 
-	// loop over file change events
+	if t.watcher != nil {
+		err = t.followFSNotify()
+	} else {
+		err = t.followPoll()
+	}
+}
+
+// followFSNotify watches t.watcher for write, rename and error events
+// until the Tail is closed, reading newly appended lines as they come in
+// and reopening the file across log rotation.
+//
+// fsnotify alone can miss a plain unlink that is never followed by a
+// recreation: on Linux, removing a file that a process still has open
+// only drops its link count, which inotify surfaces as a Chmod event (if
+// anything), never Rename or Remove, and no further Write ever arrives to
+// trigger the rotated() check. A periodic liveness check closes that gap
+// and keeps detection bounded by ReopenRetry like every other rotation
+// style.
+func (t *Tail) followFSNotify() error {
+	interval := t.poll
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+	liveness := time.NewTicker(interval)
+	defer liveness.Stop()
+
 	for {
 		var event fsnotify.Event
 		var ok bool
 
 		select {
 		case <-t.done:
-			return
+			return nil
+		case <-t.ctx.Done():
+			return nil
+		case <-liveness.C:
+			rotated, err := t.rotated()
+			if err != nil {
+				return err
+			}
+			if rotated {
+				if err := t.reopenAfterRotation(); err != nil {
+					return err
+				}
+			}
 		case event, ok = <-t.watcher.Events:
 			if !ok {
-				return
+				return nil
+			}
+			if event.Op&(fsnotify.Rename|fsnotify.Remove) != 0 {
+				if err := t.reopenAfterRotation(); err != nil {
+					return err
+				}
+				continue
 			}
 			if event.Op&fsnotify.Write == fsnotify.Write {
-				if err = t.scanLines(); err != io.EOF {
-					return
+				rotated, err := t.rotated()
+				if err != nil {
+					return err
+				}
+				if rotated {
+					if err := t.reopenAfterRotation(); err != nil {
+						return err
+					}
+					continue
+				}
+				if err := t.scanLines(); err != io.EOF {
+					return err
 				}
 			}
-		case err, ok = <-t.watcher.Errors:
-			return
+		case err, ok := <-t.watcher.Errors:
+			_ = ok
+			return err
 		}
 	}
 }
 
-// scanLines outputs lines read from file until an error or io.EOF is met,
-// or done is closed. It returns the error, or nil when done is closed.
-func (t *Tail) scanLines() error {
+// followPoll periodically Stats the file to detect growth, reading newly
+// appended lines as they come in, and reopening the file across log
+// rotation. It is used when fsnotify is unavailable or unreliable, e.g.
+// on network filesystems.
+func (t *Tail) followPoll() error {
+	interval := t.poll
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
 	for {
-		if len(t.buf) == t.nbytes {
-			tmp := make([]byte, len(t.buf)*2)
-			copy(tmp, t.buf)
-			t.buf = tmp
+		select {
+		case <-t.done:
+			return nil
+		case <-t.ctx.Done():
+			return nil
+		case <-ticker.C:
+			stat, err := os.Stat(t.fileName)
+			if os.IsNotExist(err) {
+				if err := t.reopenAfterRotation(); err != nil {
+					return err
+				}
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			fdStat, err := t.file.Stat()
+			if err != nil {
+				return err
+			}
+			if stat.Size() < t.lastSize || !os.SameFile(stat, fdStat) {
+				if err := t.reopenAfterRotation(); err != nil {
+					return err
+				}
+				continue
+			}
+			if stat.Size() > t.lastSize {
+				t.lastSize = stat.Size()
+				if err := t.scanLines(); err != io.EOF {
+					return err
+				}
+			}
 		}
-		nbytes := t.nbytes
-		n, err := t.file.Read(t.buf[t.nbytes:])
-		t.nbytes += n
+	}
+}
+
+// seekLastLines decodes every message already in the file through
+// t.decoder, keeping only the last n, then emits them. If the file
+// contains fewer than n messages, it emits all of them. n == 0 skips the
+// existing content entirely.
+//
+// It decodes forward through the whole file rather than scanning
+// backward for '\n' bytes, so it works the same regardless of which
+// Decoder is in use: a raw byte scan can only ever find the default
+// line decoder's own framing, and silently misreads or misaligns
+// anything else (length-prefixed records, NDJSON, multiline blocks).
+// t.decoder is already Reset to the start of the file by openFile, so
+// by the time this returns, decoding forward from here also leaves it
+// positioned to pick up whatever is appended next.
+func (t *Tail) seekLastLines(n int) error {
+	var tail [][]byte
+	for {
+		b, err := t.decoder.Decode()
 		if err != nil {
-			return err
+			if err != io.EOF {
+				return err
+			}
+			break
 		}
-		buf := t.buf[:t.nbytes]
-		begPos := 0
-		for i := nbytes; i < t.nbytes; i++ {
-			var line []byte
-			if buf[i] == '\n' {
-				if i > 0 && buf[i-1] == '\r' {
-					line = buf[begPos : i-1]
-				} else {
-					line = buf[begPos:i]
-				}
-				if !t.outputLine(line) {
-					return nil
-				}
-				begPos = i + 1
+		if n > 0 {
+			if len(tail) == n {
+				tail = tail[1:]
 			}
+			tail = append(tail, b)
+		}
+	}
+	for _, b := range tail {
+		if !t.outputLine(b) {
+			return nil
 		}
-		if begPos != 0 {
-			t.nbytes = copy(t.buf, buf[begPos:])
+	}
+	return nil
+}
+
+// scanLines decodes messages from the file through t.decoder and outputs
+// them until an error or io.EOF is met, or done is closed. It returns the
+// error, or nil when done is closed.
+func (t *Tail) scanLines() error {
+	for {
+		b, err := t.decoder.Decode()
+		if err != nil {
+			return err
 		}
+		if !t.outputLine(b) {
+			return nil
+		}
+	}
+}
+
+// flushDecoderTail emits any message t.decoder was still accumulating
+// when the Tail stopped (e.g. multilineDecoder's final, not-yet-closed
+// block), so it isn't silently dropped on Close the way reopenAfterRotation
+// already avoids dropping it across a rotation. It's best-effort: by the
+// time readLines' cleanup runs, the caller may already be gone, and
+// there's nothing better to do with a message nobody can receive than
+// drop it rather than leak this goroutine blocked on a full t.Line.
+func (t *Tail) flushDecoderTail() {
+	f, ok := t.decoder.(partialFlusher)
+	if !ok {
+		return
+	}
+	b, ok := f.flushPartial()
+	if !ok {
+		return
+	}
+	select {
+	case t.Line <- string(b):
+	default:
 	}
 }
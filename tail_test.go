@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"io/ioutil"
 	"os"
@@ -210,6 +211,7 @@ func TestRunTailErrors(t *testing.T) {
 
 	dummyError := errors.New("dummy error")
 	testError = dummyError
+	defer func() { testError = nil }()
 	tail := NewTail(fileName)
 
 	var err error
@@ -222,3 +224,215 @@ func TestRunTailErrors(t *testing.T) {
 		t.Fatal("expected error, got", err)
 	}
 }
+
+func TestTailLastLines(t *testing.T) {
+	cases := []struct {
+		name    string
+		content string
+		n       int
+		want    []string
+	}{
+		{"exact count with trailing newline", "line1\nline2\n", 1, []string{"line2"}},
+		{"fewer lines than n", "line1\nline2\n", 5, []string{"line1", "line2"}},
+		{"n zero skips backlog", "line1\nline2\n", 0, nil},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			testFile := createTmpFile(t)
+			fileName := testFile.Name()
+			testFile.Write([]byte(c.content))
+			testFile.Close()
+			defer os.Remove(fileName)
+
+			tail := NewTailWithOptions(fileName, WithTailLines(c.n))
+			defer tail.Close()
+
+			for _, want := range c.want {
+				select {
+				case line := <-tail.Line:
+					if line != want {
+						t.Fatal("expected", want, "got", line)
+					}
+				case <-time.After(2 * time.Second):
+					t.Fatal("timed out waiting for", want)
+				}
+			}
+
+			select {
+			case line := <-tail.Line:
+				t.Fatal("unexpected extra line", line)
+			case <-time.After(200 * time.Millisecond):
+			}
+		})
+	}
+}
+
+func TestCopyTruncateRotation(t *testing.T) {
+	testFile := createTmpFile(t)
+	fileName := testFile.Name()
+	testFile.Write([]byte("line 1\nline 2\n"))
+	defer testFile.Close()
+	defer os.Remove(fileName)
+
+	tail := NewTail(fileName)
+	defer tail.Close()
+
+	line := <-tail.Line
+	if line != "line 1" {
+		t.Fatal("expected 'line 1', got", line)
+	}
+	line = <-tail.Line
+	if line != "line 2" {
+		t.Fatal("expected 'line 2', got", line)
+	}
+
+	// copytruncate: truncate the same fd/inode in place and write less
+	// data than was there before, as logrotate's copytruncate mode does
+	if err := testFile.Truncate(0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := testFile.WriteAt([]byte("line 3\n"), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case line = <-tail.Line:
+		if line != "line 3" {
+			t.Fatal("expected 'line 3', got", line)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("timed out waiting for 'line 3' after copytruncate")
+	}
+}
+
+func TestRemoveWithoutRecreateBoundedByReopenRetry(t *testing.T) {
+	testFile := createTmpFile(t)
+	fileName := testFile.Name()
+	testFile.Write([]byte("line 1\n"))
+	defer testFile.Close()
+
+	tail := NewTailWithOptions(fileName, WithReopenRetry(500*time.Millisecond))
+	defer tail.Close()
+
+	line := <-tail.Line
+	if line != "line 1" {
+		t.Fatal("expected 'line 1', got", line)
+	}
+
+	if err := os.Remove(fileName); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-tail.Error:
+		if err == nil {
+			t.Fatal("expected a non-nil error")
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Tail did not give up within ReopenRetry after being removed without a replacement")
+	}
+	if !tail.IsClosed() {
+		t.Fatal("expected tail to be closed after giving up on reopen")
+	}
+}
+
+func TestTailContextCancel(t *testing.T) {
+	testFile := createTmpFile(t)
+	fileName := testFile.Name()
+	testFile.Write([]byte("line 1\n"))
+	defer testFile.Close()
+	defer os.Remove(fileName)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	tail := NewTailContext(ctx, fileName)
+	defer tail.Close()
+
+	line := <-tail.Line
+	if line != "line 1" {
+		t.Fatal("expected 'line 1', got", line)
+	}
+
+	cancel()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !tail.IsClosed() {
+		if time.Now().After(deadline) {
+			t.Fatal("Tail did not close after its context was cancelled")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestBackendPollGrowthAndRotation(t *testing.T) {
+	testFile := createTmpFile(t)
+	fileName := testFile.Name()
+	testFile.Write([]byte("line 1\n"))
+	defer testFile.Close()
+	defer os.Remove(fileName)
+
+	tail := NewTailWithOptions(fileName, WithBackend(BackendPoll), WithPollInterval(50*time.Millisecond))
+	defer tail.Close()
+
+	line := <-tail.Line
+	if line != "line 1" {
+		t.Fatal("expected 'line 1', got", line)
+	}
+
+	testFile.Write([]byte("line 2\n"))
+	select {
+	case line = <-tail.Line:
+		if line != "line 2" {
+			t.Fatal("expected 'line 2', got", line)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for 'line 2'")
+	}
+
+	os.Rename(fileName, fileName+"x")
+	defer os.Remove(fileName + "x")
+	testFile, err := os.Create(fileName)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	testFile.Write([]byte("line 3\n"))
+	defer testFile.Close()
+
+	select {
+	case line = <-tail.Line:
+		if line != "line 3" {
+			t.Fatal("expected 'line 3', got", line)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for 'line 3' after rotation")
+	}
+}
+
+func TestBackendAutoFallsBackToPoll(t *testing.T) {
+	testFile := createTmpFile(t)
+	fileName := testFile.Name()
+	testFile.Write([]byte("line 1\n"))
+	defer testFile.Close()
+	defer os.Remove(fileName)
+
+	testError2 = errors.New("fsnotify watcher.Add unsupported on this filesystem")
+	defer func() { testError2 = nil }()
+
+	tail := NewTailWithOptions(fileName, WithBackend(BackendAuto), WithPollInterval(50*time.Millisecond))
+	defer tail.Close()
+
+	line := <-tail.Line
+	if line != "line 1" {
+		t.Fatal("expected 'line 1', got", line)
+	}
+
+	testFile.Write([]byte("line 2\n"))
+	select {
+	case line = <-tail.Line:
+		if line != "line 2" {
+			t.Fatal("expected 'line 2', got", line)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for 'line 2' via poll fallback")
+	}
+}